@@ -0,0 +1,54 @@
+// File: checkpoint.go
+
+package payouts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckpointMismatch reports that an observed ancestor hash disagrees with a
+// network's pinned checkpoint, meaning the chain we're looking at has
+// reorged past a point we trust.
+type CheckpointMismatch struct {
+	Height   int64
+	Expected string
+	Observed string
+}
+
+func (e *CheckpointMismatch) Error() string {
+	return fmt.Sprintf("checkpoint mismatch at height %d: expected hash %s, observed %s", e.Height, e.Expected, e.Observed)
+}
+
+// VerifyCheckpoints walks network's checkpoint list back from candidateHeight
+// and confirms that the highest checkpoint at or below it matches the
+// corresponding entry in observedHashes (height -> hash, as seen from the
+// node). It returns a *CheckpointMismatch if the chain disagrees with a
+// checkpoint, so callers can refuse to unlock rather than silently pay out a
+// block that sits on an orphaned fork.
+func VerifyCheckpoints(network Network, candidateHeight int64, observedHashes map[int64]string) error {
+	checkpoints := network.CheckpointList()
+
+	var highest *Checkpoint
+	for i := range checkpoints {
+		cp := &checkpoints[i]
+		if cp.Height > candidateHeight {
+			continue
+		}
+		if highest == nil || cp.Height > highest.Height {
+			highest = cp
+		}
+	}
+	if highest == nil {
+		return nil
+	}
+
+	observed, ok := observedHashes[highest.Height]
+	if !ok {
+		return fmt.Errorf("payouts: no observed hash at checkpoint height %d for network %s", highest.Height, network.Name())
+	}
+	if !strings.EqualFold(observed, highest.Hash) {
+		return &CheckpointMismatch{Height: highest.Height, Expected: highest.Hash, Observed: observed}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package payouts
+
+import "testing"
+
+func networkWithCheckpoints(checkpoints []Checkpoint) Network {
+	return newEpochNetwork("test", []RewardEpoch{{Height: 0, Reward: weiRat("1000000000000000000")}}, 100, false, checkpoints, 0)
+}
+
+func TestVerifyCheckpointsNoCheckpoints(t *testing.T) {
+	network := networkWithCheckpoints(nil)
+	if err := VerifyCheckpoints(network, 500, nil); err != nil {
+		t.Errorf("expected no error with no checkpoints, got %v", err)
+	}
+}
+
+func TestVerifyCheckpointsMatch(t *testing.T) {
+	network := networkWithCheckpoints([]Checkpoint{
+		{Height: 100, Hash: "0xaaaa"},
+		{Height: 200, Hash: "0xbbbb"},
+	})
+	observed := map[int64]string{200: "0xBBBB"} // case-insensitive match
+	if err := VerifyCheckpoints(network, 250, observed); err != nil {
+		t.Errorf("expected matching checkpoint to pass, got %v", err)
+	}
+}
+
+func TestVerifyCheckpointsMismatch(t *testing.T) {
+	network := networkWithCheckpoints([]Checkpoint{{Height: 100, Hash: "0xaaaa"}})
+	observed := map[int64]string{100: "0xdead"}
+	err := VerifyCheckpoints(network, 150, observed)
+	if err == nil {
+		t.Fatal("expected a checkpoint mismatch error")
+	}
+	mismatch, ok := err.(*CheckpointMismatch)
+	if !ok {
+		t.Fatalf("expected *CheckpointMismatch, got %T", err)
+	}
+	if mismatch.Height != 100 || mismatch.Expected != "0xaaaa" || mismatch.Observed != "0xdead" {
+		t.Errorf("unexpected mismatch details: %+v", mismatch)
+	}
+}
+
+func TestVerifyCheckpointsMissingObservation(t *testing.T) {
+	network := networkWithCheckpoints([]Checkpoint{{Height: 100, Hash: "0xaaaa"}})
+	if err := VerifyCheckpoints(network, 150, map[int64]string{}); err == nil {
+		t.Fatal("expected an error when the observed hash at the checkpoint height is missing")
+	}
+}
+
+func TestVerifyCheckpointsPicksHighestAtOrBelow(t *testing.T) {
+	network := networkWithCheckpoints([]Checkpoint{
+		{Height: 100, Hash: "0xaaaa"},
+		{Height: 200, Hash: "0xbbbb"},
+		{Height: 300, Hash: "0xcccc"},
+	})
+	// Candidate sits between checkpoints 200 and 300, so only 200 matters.
+	observed := map[int64]string{200: "0xbbbb", 300: "0xwrong"}
+	if err := VerifyCheckpoints(network, 250, observed); err != nil {
+		t.Errorf("expected checkpoint 200 to be the gating checkpoint, got %v", err)
+	}
+}
+
+func TestMinConfirmationsDefaults(t *testing.T) {
+	network := newEpochNetwork("test", []RewardEpoch{{Height: 0, Reward: weiRat("1")}}, 1, false, nil, 0)
+	if network.MinConfirmations() != defaultMinConfirmations {
+		t.Errorf("MinConfirmations() = %d, want default %d", network.MinConfirmations(), defaultMinConfirmations)
+	}
+}
+
+func TestMinConfirmationsPerNetwork(t *testing.T) {
+	if ZetherMainnet.MinConfirmations() == ZetherTestnet.MinConfirmations() {
+		t.Error("expected mainnet and testnet to have distinct confirmation depths")
+	}
+}
@@ -0,0 +1,19 @@
+// File: config.go
+
+package payouts
+
+// PayoutsConfig configures the real, chain-scanning BlockUnlocker.
+type PayoutsConfig struct {
+	Enabled         bool   `json:"enabled"`
+	Network         string `json:"network"`
+	Daemon          string `json:"daemon"`
+	Timeout         string `json:"timeout"`
+	PollInterval    string `json:"pollInterval"`
+	MatureDepth     int64  `json:"matureDepth"`
+	UnlockBatchSize int64  `json:"unlockBatchSize"`
+	// KeepUncles controls whether classify matches candidates against
+	// uncles at all and whether applyReward adds nephew credit for any
+	// uncles a canonical block included; with it false, uncle blocks age
+	// into orphans and canonical blocks earn no nephew bonus.
+	KeepUncles bool `json:"keepUncles"`
+}
@@ -0,0 +1,109 @@
+package payouts
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRewardAtDeterministic(t *testing.T) {
+	networks := []Network{ZetherMainnet, ZetherTestnet}
+	for _, n := range networks {
+		for height := int64(0); height < 400000; height += 37 {
+			first := n.RewardAt(height)
+			second := n.RewardAt(height)
+			if first.Cmp(second) != 0 {
+				t.Fatalf("%s: RewardAt(%d) not deterministic: %s vs %s", n.Name(), height, first, second)
+			}
+		}
+	}
+}
+
+func TestZetherMainnetRewardAt(t *testing.T) {
+	cases := []struct {
+		height int64
+		wei    string
+	}{
+		{0, "50000000000000000000"},
+		{99999, "50000000000000000000"},
+		{100000, "25000000000000000000"},
+		{199999, "25000000000000000000"},
+		{200000, "12500000000000000000"},
+		{300000, "6250000000000000000"},
+		{999999, "6250000000000000000"},
+	}
+	for _, c := range cases {
+		got := ZetherMainnet.RewardAt(c.height)
+		want := weiRat(c.wei)
+		if got.Cmp(want) != 0 {
+			t.Errorf("RewardAt(%d) = %s, want %s", c.height, got, want)
+		}
+	}
+}
+
+func TestZetherTestnetRewardAt(t *testing.T) {
+	cases := []struct {
+		height int64
+		wei    string
+	}{
+		{0, "50000000000000000000"},
+		{999, "50000000000000000000"},
+		{1000, "25000000000000000000"},
+		{2000, "12500000000000000000"},
+		{3000, "6250000000000000000"},
+	}
+	for _, c := range cases {
+		got := ZetherTestnet.RewardAt(c.height)
+		want := weiRat(c.wei)
+		if got.Cmp(want) != 0 {
+			t.Errorf("RewardAt(%d) = %s, want %s", c.height, got, want)
+		}
+	}
+}
+
+func TestRewardAtBeforeGenesisIsZero(t *testing.T) {
+	n := newEpochNetwork("test", []RewardEpoch{{Height: 10, Reward: big.NewRat(5, 1)}}, 10, false, nil, 0)
+	if got := n.RewardAt(0); got.Sign() != 0 {
+		t.Errorf("RewardAt before first epoch = %s, want 0", got)
+	}
+}
+
+func TestNewCustomNetwork(t *testing.T) {
+	network, err := NewCustomNetwork(NetworkConfig{
+		Name: "custom",
+		RewardSchedule: []RewardScheduleEntry{
+			{Height: 0, Reward: "1000000000000000000"},
+			{Height: 500, Reward: "500000000000000000"},
+		},
+		AdjustmentInterval: 500,
+		HasUncles:          true,
+	})
+	if err != nil {
+		t.Fatalf("NewCustomNetwork returned error: %v", err)
+	}
+	if got, want := network.RewardAt(0), weiRat("1000000000000000000"); got.Cmp(want) != 0 {
+		t.Errorf("RewardAt(0) = %s, want %s", got, want)
+	}
+	if got, want := network.RewardAt(500), weiRat("500000000000000000"); got.Cmp(want) != 0 {
+		t.Errorf("RewardAt(500) = %s, want %s", got, want)
+	}
+	if !network.HasUncles() {
+		t.Error("expected HasUncles to be true")
+	}
+}
+
+func TestNewCustomNetworkRejectsUnparseableReward(t *testing.T) {
+	_, err := NewCustomNetwork(NetworkConfig{
+		Name:           "custom",
+		RewardSchedule: []RewardScheduleEntry{{Height: 0, Reward: "not-a-number"}},
+	})
+	if err == nil {
+		t.Fatal("expected an error for an unparseable reward")
+	}
+}
+
+func TestNewCustomNetworkRejectsEmptySchedule(t *testing.T) {
+	_, err := NewCustomNetwork(NetworkConfig{Name: "custom"})
+	if err == nil {
+		t.Fatal("expected an error for an empty reward schedule")
+	}
+}
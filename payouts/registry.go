@@ -0,0 +1,48 @@
+// File: registry.go
+
+package payouts
+
+import (
+	"fmt"
+	"sync"
+)
+
+// registry holds the networks available to the payouts subsystem by name,
+// seeded with the two networks baked into the binary and extendable at
+// startup via RegisterNetwork.
+var registry = struct {
+	mu       sync.RWMutex
+	networks map[string]Network
+}{
+	networks: map[string]Network{
+		"zether":         ZetherMainnet,
+		"zether-testnet": ZetherTestnet,
+	},
+}
+
+// RegisterNetwork validates cfg and makes the resulting Network available to
+// LookupNetwork under cfg.Name, letting operators onboard forks and private
+// testnets from a config file without recompiling.
+func RegisterNetwork(cfg NetworkConfig) error {
+	network, err := NewCustomNetwork(cfg)
+	if err != nil {
+		return err
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.networks[cfg.Name] = network
+	return nil
+}
+
+// LookupNetwork returns the network registered under name, or an error if no
+// such network has been registered.
+func LookupNetwork(name string) (Network, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	network, ok := registry.networks[name]
+	if !ok {
+		return nil, fmt.Errorf("payouts: no network registered under name %q", name)
+	}
+	return network, nil
+}
@@ -0,0 +1,109 @@
+package payouts
+
+import "testing"
+
+func TestLookupNetworkBuiltins(t *testing.T) {
+	if n, err := LookupNetwork("zether"); err != nil || n != ZetherMainnet {
+		t.Errorf("LookupNetwork(zether) = %v, %v; want ZetherMainnet, nil", n, err)
+	}
+	if n, err := LookupNetwork("zether-testnet"); err != nil || n != ZetherTestnet {
+		t.Errorf("LookupNetwork(zether-testnet) = %v, %v; want ZetherTestnet, nil", n, err)
+	}
+}
+
+func TestLookupNetworkUnknown(t *testing.T) {
+	if _, err := LookupNetwork("does-not-exist"); err == nil {
+		t.Fatal("expected an error looking up an unregistered network")
+	}
+}
+
+func TestRegisterNetworkRoundTrip(t *testing.T) {
+	cfg := NetworkConfig{
+		Name: "test-fork",
+		RewardSchedule: []RewardScheduleEntry{
+			{Height: 0, Reward: "2000000000000000000"},
+		},
+		AdjustmentInterval: 100,
+		HasUncles:          true,
+		MinConfirmations:   12,
+		Checkpoints:        []CheckpointConfig{{Height: 0, Hash: "0xgenesis"}},
+		ChainID:            1337,
+		EIP1559:            true,
+	}
+	if err := RegisterNetwork(cfg); err != nil {
+		t.Fatalf("RegisterNetwork returned error: %v", err)
+	}
+
+	network, err := LookupNetwork("test-fork")
+	if err != nil {
+		t.Fatalf("LookupNetwork returned error: %v", err)
+	}
+	if got, want := network.RewardAt(0), weiRat("2000000000000000000"); got.Cmp(want) != 0 {
+		t.Errorf("RewardAt(0) = %s, want %s", got, want)
+	}
+	if !network.HasUncles() {
+		t.Error("expected HasUncles to be true")
+	}
+	if len(network.CheckpointList()) != 1 {
+		t.Errorf("expected 1 checkpoint, got %d", len(network.CheckpointList()))
+	}
+}
+
+func TestRegisterNetworkRejectsInvalidConfig(t *testing.T) {
+	err := RegisterNetwork(NetworkConfig{Name: "broken"})
+	if err == nil {
+		t.Fatal("expected an error registering a network with no reward schedule")
+	}
+	if _, lookupErr := LookupNetwork("broken"); lookupErr == nil {
+		t.Error("a failed RegisterNetwork call must not register a partial network")
+	}
+}
+
+func TestNewCustomNetworkRejectsUnsortedSchedule(t *testing.T) {
+	_, err := NewCustomNetwork(NetworkConfig{
+		Name: "custom",
+		RewardSchedule: []RewardScheduleEntry{
+			{Height: 100, Reward: "1000000000000000000"},
+			{Height: 50, Reward: "500000000000000000"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a non-increasing reward schedule")
+	}
+}
+
+func TestNewCustomNetworkRejectsNegativeReward(t *testing.T) {
+	_, err := NewCustomNetwork(NetworkConfig{
+		Name:           "custom",
+		RewardSchedule: []RewardScheduleEntry{{Height: 0, Reward: "-1"}},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a negative reward")
+	}
+}
+
+func TestNewCustomNetworkRejectsUint256Overflow(t *testing.T) {
+	// 2^256, one past the largest value a uint256 balance can hold.
+	const overflow = "115792089237316195423570985008687907853269984665640564039457584007913129639936"
+	_, err := NewCustomNetwork(NetworkConfig{
+		Name:           "custom",
+		RewardSchedule: []RewardScheduleEntry{{Height: 0, Reward: overflow}},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a reward overflowing uint256")
+	}
+}
+
+func TestNewCustomNetworkRejectsUnsortedCheckpoints(t *testing.T) {
+	_, err := NewCustomNetwork(NetworkConfig{
+		Name:           "custom",
+		RewardSchedule: []RewardScheduleEntry{{Height: 0, Reward: "1"}},
+		Checkpoints: []CheckpointConfig{
+			{Height: 100, Hash: "0xaaaa"},
+			{Height: 50, Hash: "0xbbbb"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error for non-increasing checkpoint heights")
+	}
+}
@@ -0,0 +1,48 @@
+// File: uncle.go
+
+package payouts
+
+import "math/big"
+
+// uncleMaxDepth is the deepest an uncle can trail its nephew and still earn a
+// reward, matching the Ethereum-style formula shared by ethash-family chains.
+const uncleMaxDepth = 6
+
+// uncleRewardDivisor and nephewRewardDivisor are the denominators of the
+// uncle and nephew reward formulas respectively. These stay fixed across
+// reward eras, matching every Ethereum-family fork from Frontier onward: what
+// actually varies era to era is the base block reward (5/3/2 ETH-equivalent
+// at the Frontier/Byzantium/Constantinople boundaries), which both formulas
+// already pick up by reading it from Network.RewardAt(blockHeight) rather
+// than hardcoding it. BlockUnlocker.classify sets candidate.Uncle and
+// candidate.UncleHeight when it resolves a candidate against an uncle, so
+// applyReward calls UncleReward with the real includer height rather than
+// the uncle's own mined height; the resulting candidate then flows through
+// the same BlockUnlocker.creditRound path as a canonical block.
+var uncleRewardDivisor = big.NewInt(8)
+var nephewRewardDivisor = big.NewInt(32)
+
+// UncleReward computes the reward, in wei, owed to the miner of an uncle at
+// uncleHeight that was included by the canonical block at blockHeight:
+//
+//	reward = ((uncleHeight + 8 - blockHeight) * baseReward) / 8
+//
+// clamped to zero once the uncle trails by more than uncleMaxDepth blocks.
+// baseReward is taken from RewardAt(blockHeight) so the amount tracks
+// whichever fork's schedule is in effect at the nephew's height.
+func (n *epochNetwork) UncleReward(blockHeight, uncleHeight int64) *big.Rat {
+	depth := blockHeight - uncleHeight
+	if depth <= 0 || depth > uncleMaxDepth {
+		return new(big.Rat)
+	}
+	baseReward := n.RewardAt(blockHeight)
+	factor := big.NewRat(uncleHeight+8-blockHeight, 1)
+	return new(big.Rat).Quo(new(big.Rat).Mul(baseReward, factor), new(big.Rat).SetInt(uncleRewardDivisor))
+}
+
+// NephewReward returns the bonus, in wei, paid to the canonical block's miner
+// at blockHeight for including a single uncle: baseReward / 32.
+func NephewReward(network Network, blockHeight int64) *big.Rat {
+	baseReward := network.RewardAt(blockHeight)
+	return new(big.Rat).Quo(baseReward, new(big.Rat).SetInt(nephewRewardDivisor))
+}
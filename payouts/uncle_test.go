@@ -0,0 +1,73 @@
+package payouts
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestUncleRewardByDepth(t *testing.T) {
+	// Base reward at height 100 on ZetherMainnet is 50 ETH-equivalent.
+	expected := map[int64]string{
+		1: "43750000000000000000",
+		2: "37500000000000000000",
+		3: "31250000000000000000",
+		4: "25000000000000000000",
+		5: "18750000000000000000",
+		6: "12500000000000000000",
+	}
+	for depth := int64(1); depth <= 6; depth++ {
+		blockHeight := int64(100)
+		uncleHeight := blockHeight - depth
+		got := ZetherMainnet.UncleReward(blockHeight, uncleHeight)
+		want := weiRat(expected[depth])
+		if got.Cmp(want) != 0 {
+			t.Errorf("depth %d: UncleReward = %s, want %s", depth, got, want)
+		}
+	}
+}
+
+func TestUncleRewardBeyondMaxDepthIsZero(t *testing.T) {
+	blockHeight := int64(100)
+	for depth := int64(7); depth < 12; depth++ {
+		got := ZetherMainnet.UncleReward(blockHeight, blockHeight-depth)
+		if got.Sign() != 0 {
+			t.Errorf("depth %d: UncleReward = %s, want 0", depth, got)
+		}
+	}
+}
+
+func TestUncleRewardNonPositiveDepthIsZero(t *testing.T) {
+	blockHeight := int64(100)
+	if got := ZetherMainnet.UncleReward(blockHeight, blockHeight); got.Sign() != 0 {
+		t.Errorf("same-height uncle reward = %s, want 0", got)
+	}
+	if got := ZetherMainnet.UncleReward(blockHeight, blockHeight+1); got.Sign() != 0 {
+		t.Errorf("future uncle reward = %s, want 0", got)
+	}
+}
+
+func TestNephewReward(t *testing.T) {
+	got := NephewReward(ZetherMainnet, 1)
+	want := weiRat("1562500000000000000") // 50 ETH-equivalent / 32
+	if got.Cmp(want) != 0 {
+		t.Errorf("NephewReward = %s, want %s", got, want)
+	}
+}
+
+func TestMultipleUnclesPerBlock(t *testing.T) {
+	blockHeight := int64(100)
+	uncles := []int64{blockHeight - 1, blockHeight - 2, blockHeight - 3}
+
+	total := ZetherMainnet.RewardAt(blockHeight)
+	for _, uncleHeight := range uncles {
+		total = new(big.Rat).Add(total, NephewReward(ZetherMainnet, blockHeight))
+		if ZetherMainnet.UncleReward(blockHeight, uncleHeight).Sign() <= 0 {
+			t.Errorf("expected a positive uncle reward for uncle at %d", uncleHeight)
+		}
+	}
+
+	wantTotal := new(big.Rat).Add(ZetherMainnet.RewardAt(blockHeight), new(big.Rat).Mul(big.NewRat(3, 1), NephewReward(ZetherMainnet, blockHeight)))
+	if total.Cmp(wantTotal) != 0 {
+		t.Errorf("total with 3 uncles = %s, want %s", total, wantTotal)
+	}
+}
@@ -5,90 +5,459 @@ package payouts
 import (
 	"fmt"
 	"log"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/cron"
+
+	"github.com/yuriy0803/open-etc-pool-friends/rpc"
+	"github.com/yuriy0803/open-etc-pool-friends/storage"
 )
 
-// Define network constants
+// shannon is the unit miners' per-round credits are recorded in (1 Shannon =
+// 1e9 wei); distributeReward converts each miner's wei share into it.
+var shannon = big.NewInt(1e9)
+
+// CandidateStatus describes the disposition of a round candidate once it has
+// accumulated enough confirmations to evaluate.
+type CandidateStatus int
+
 const (
-	ZetherMainnet = "ZetherMainnet"
-	ZetherTestnet = "ZetherTestnet"
+	StatusImmature CandidateStatus = iota
+	StatusMatured
+	StatusOrphan
+	StatusLost
 )
 
-// NetworkConfig holds network-specific configurations
-type NetworkConfig struct {
-	Name          string
-	BlockReward   map[int64]float64 // Block height -> Reward
-	AdjustmentFreq int64            // Frequency of reward adjustment in blocks
-	HasUncles     bool              // Indicates if the network considers uncle blocks
+func (s CandidateStatus) String() string {
+	switch s {
+	case StatusMatured:
+		return "matured"
+	case StatusOrphan:
+		return "orphan"
+	case StatusLost:
+		return "lost"
+	default:
+		return "immature"
+	}
+}
+
+// scanDepth is how far around a candidate's logged height we search for the
+// actual block or uncle, since the height recorded at submission time is
+// only a reference point (geth doesn't give a consistent view of height and
+// job together).
+const scanDepth = 16
+
+// nodeRPC is the subset of rpc.RPCClient the unlocker depends on, narrowed to
+// a local interface so tests can drive it against a fake transport.
+type nodeRPC interface {
+	GetPendingBlock() (*rpc.GetBlockReplyPart, error)
+	GetBlockByHeight(height int64) (*rpc.GetBlockReply, error)
+	GetUncleByBlockNumberAndIndex(height int64, index int) (*rpc.GetBlockReply, error)
+	GetTxReceipt(hash string) (*rpc.TxReceipt, error)
 }
 
-// Reward schedule for Zether mainnet
-var ZetherRewards = map[int64]float64{
-	0:       50.0,  // Initial reward
-	100000:  25.0,  // Reduced reward
-	200000:  12.5,  // Further reduced
-	300000:   6.25, // Final reduction
+// unlockerBackend is the subset of storage.RedisClient the unlocker depends
+// on.
+type unlockerBackend interface {
+	GetCandidates(maxHeight int64) ([]*storage.BlockData, error)
+	GetImmatureBlocks(maxHeight int64) ([]*storage.BlockData, error)
+	GetRoundShares(height int64, nonce string) (map[string]int64, error)
+	WritePendingOrphans(blocks []*storage.BlockData) error
+	WriteOrphan(block *storage.BlockData) error
+	WriteImmatureBlock(block *storage.BlockData, roundRewards map[string]int64) error
+	WriteMaturedBlock(block *storage.BlockData, roundRewards map[string]int64) error
 }
 
-// Reward schedule for ZTH-Test network
-var TestnetRewards = map[int64]float64{
-	0:    50.0,
-	1000: 25.0,
-	2000: 12.5,
-	3000:  6.25,
+// BlockUnlocker pulls round candidates out of Redis, checks them against the
+// node, and credits miners once they're safely matured.
+type BlockUnlocker struct {
+	config   *PayoutsConfig
+	network  Network
+	backend  unlockerBackend
+	rpc      nodeRPC
+	halt     bool
+	lastFail error
 }
 
-// Define network configurations
-var ZetherNetwork = NetworkConfig{
-	Name:          ZetherMainnet,
-	BlockReward:   ZetherRewards,
-	AdjustmentFreq: 100000,
-	HasUncles:     false,
+// NewBlockUnlocker builds a BlockUnlocker for network, talking to backend and
+// to the node at cfg.Daemon.
+func NewBlockUnlocker(cfg *PayoutsConfig, network Network, backend *storage.RedisClient) *BlockUnlocker {
+	if cfg.MatureDepth < network.MinConfirmations() {
+		log.Fatalf("payouts: matureDepth can't be < network MinConfirmations (%v), got %v", network.MinConfirmations(), cfg.MatureDepth)
+	}
+	if cfg.UnlockBatchSize < 1 {
+		log.Fatalf("payouts: unlockBatchSize must be positive, got %v", cfg.UnlockBatchSize)
+	}
+	return &BlockUnlocker{
+		config:  cfg,
+		network: network,
+		backend: backend,
+		rpc:     rpc.NewRPCClient("BlockUnlocker", cfg.Daemon, cfg.Timeout),
+	}
 }
 
-var ZetherTestnet = NetworkConfig{
-	Name:          ZetherTestnet,
-	BlockReward:   TestnetRewards,
-	AdjustmentFreq: 1000,
-	HasUncles:     false,
+// Start runs the unlock loop on a cron schedule, unlocking immediately on
+// startup and then every cfg.PollInterval thereafter.
+func (u *BlockUnlocker) Start() {
+	log.Printf("Starting block unlocker for %s, interval %s", u.network.Name(), u.config.PollInterval)
+	u.unlockPendingBlocks()
+	u.unlockAndCreditMiners()
+
+	c := cron.New()
+	if err := c.AddFunc("@every "+u.config.PollInterval, func() {
+		u.unlockPendingBlocks()
+		u.unlockAndCreditMiners()
+	}); err != nil {
+		log.Fatalf("payouts: invalid pollInterval %q: %v", u.config.PollInterval, err)
+	}
+	c.Start()
 }
 
-// GetReward calculates the block reward based on network configuration and block height
-func GetReward(config NetworkConfig, blockHeight int64) float64 {
-	reward := 0.0
-	for height, r := range config.BlockReward {
-		if blockHeight >= height {
-			reward = r
-		} else {
-			break
+// unlockPendingBlocks moves freshly-confirmed candidates into the immature
+// pool, crediting their round shares provisionally.
+func (u *BlockUnlocker) unlockPendingBlocks() {
+	if u.halt {
+		log.Println("Unlocking suspended due to last critical error:", u.lastFail)
+		return
+	}
+
+	tipHeight, err := u.currentHeight()
+	if err != nil {
+		u.fail(err, "Unable to get current blockchain height from node")
+		return
+	}
+
+	candidates, err := u.backend.GetCandidates(tipHeight - u.network.MinConfirmations())
+	if err != nil {
+		u.fail(err, "Failed to get block candidates from backend")
+		return
+	}
+	if len(candidates) == 0 {
+		log.Println("No block candidates to unlock")
+		return
+	}
+
+	matured, orphaned, err := u.resolveCandidates(candidates)
+	if err != nil {
+		u.fail(err, "Failed to resolve block candidates")
+		return
+	}
+
+	if err := u.backend.WritePendingOrphans(orphaned); err != nil {
+		u.fail(err, "Failed to insert orphaned blocks into backend")
+		return
+	}
+	log.Printf("Inserted %v orphaned blocks to backend", len(orphaned))
+
+	for _, block := range matured {
+		if err := u.creditRound(block, u.backend.WriteImmatureBlock); err != nil {
+			u.fail(err, fmt.Sprintf("Failed to credit immature round %v", block.RoundHeight))
+			return
 		}
 	}
-	return reward
+	log.Printf("Immature %v blocks, %v orphans", len(matured), len(orphaned))
 }
 
-// SimulateUnlock simulates the unlock process, calculating rewards for a given block height
-func SimulateUnlock(networkConfig NetworkConfig, blockHeight int64) {
-	if blockHeight < 0 {
-		log.Fatalf("Invalid block height: %d", blockHeight)
+// unlockAndCreditMiners finalizes immature blocks that have passed
+// cfg.MatureDepth confirmations, crediting them as matured.
+func (u *BlockUnlocker) unlockAndCreditMiners() {
+	if u.halt {
+		log.Println("Unlocking suspended due to last critical error:", u.lastFail)
+		return
 	}
 
-	reward := GetReward(networkConfig, blockHeight)
-	fmt.Printf("Network: %s\n", networkConfig.Name)
-	fmt.Printf("Block Height: %d\n", blockHeight)
-	fmt.Printf("Block Reward: %.2f ZTH\n", reward)
+	tipHeight, err := u.currentHeight()
+	if err != nil {
+		u.fail(err, "Unable to get current blockchain height from node")
+		return
+	}
 
-	if !networkConfig.HasUncles {
-		fmt.Println("Note: This network does not include uncle blocks.")
+	immature, err := u.backend.GetImmatureBlocks(tipHeight - u.config.MatureDepth)
+	if err != nil {
+		u.fail(err, "Failed to get immature blocks from backend")
+		return
+	}
+	if len(immature) == 0 {
+		log.Println("No immature blocks to credit miners")
+		return
 	}
+
+	matured, orphaned, err := u.resolveCandidates(immature)
+	if err != nil {
+		u.fail(err, "Failed to resolve immature blocks")
+		return
+	}
+
+	for _, block := range orphaned {
+		if err := u.backend.WriteOrphan(block); err != nil {
+			u.fail(err, "Failed to insert orphaned block into backend")
+			return
+		}
+	}
+
+	for _, block := range matured {
+		if err := u.creditRound(block, u.backend.WriteMaturedBlock); err != nil {
+			u.fail(err, fmt.Sprintf("Failed to credit matured round %v", block.RoundHeight))
+			return
+		}
+	}
+	log.Printf("Unlocked %v blocks, %v orphans", len(matured), len(orphaned))
+}
+
+func (u *BlockUnlocker) fail(err error, msg string) {
+	u.halt = true
+	u.lastFail = err
+	log.Printf("%s: %v", msg, err)
+}
+
+func (u *BlockUnlocker) currentHeight() (int64, error) {
+	pending, err := u.rpc.GetPendingBlock()
+	if err != nil {
+		return 0, err
+	}
+	return parseHexInt64(pending.Number)
+}
+
+// resolveCandidates classifies each candidate by searching the chain around
+// its logged height, separating those that matured on the canonical chain
+// (or as an uncle) from those that turned out to be orphaned.
+func (u *BlockUnlocker) resolveCandidates(candidates []*storage.BlockData) (matured, orphaned []*storage.BlockData, err error) {
+	batchSize := int(u.config.UnlockBatchSize)
+	if batchSize > 0 && batchSize < len(candidates) {
+		candidates = candidates[:batchSize]
+	}
+
+	for _, candidate := range candidates {
+		status, block, includerHeight, ancestorHashes, err := u.classify(candidate)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch status {
+		case StatusMatured:
+			if err := u.verifyCheckpoints(includerHeight, ancestorHashes); err != nil {
+				return nil, nil, fmt.Errorf("refusing to unlock round %v: %w", candidate.RoundKey(), err)
+			}
+			if err := u.applyReward(candidate, block, includerHeight); err != nil {
+				return nil, nil, err
+			}
+			matured = append(matured, candidate)
+		case StatusOrphan, StatusLost:
+			candidate.Orphan = true
+			orphaned = append(orphaned, candidate)
+		}
+	}
+	return matured, orphaned, nil
 }
 
-func main() {
-	// Test mainnet reward schedule
-	fmt.Println("---- Zether Mainnet ----")
-	SimulateUnlock(ZetherNetwork, 50000)  // Check reward before adjustment
-	SimulateUnlock(ZetherNetwork, 150000) // Check reward after first adjustment
+// classify searches for the candidate's block (or the uncle that contains
+// it) within scanDepth of its logged height, returning the matching block,
+// the height of the canonical (includer) block reward math should be based
+// on, and the ancestor hashes observed along the way. For a canonical match,
+// block and the includer height describe the same block. For an uncle
+// match, block is the uncle's own GetBlockReply (mined at a lower height
+// than the includer), so candidate.Uncle and candidate.UncleHeight are set
+// here, before applyReward ever runs.
+func (u *BlockUnlocker) classify(candidate *storage.BlockData) (status CandidateStatus, block *rpc.GetBlockReply, includerHeight int64, ancestorHashes map[int64]string, err error) {
+	if candidate.Height < scanDepth {
+		return StatusImmature, nil, 0, nil, nil
+	}
+
+	ancestorHashes = make(map[int64]string)
+	for i := int64(-scanDepth); i < scanDepth; i++ {
+		height := candidate.Height + i
+		if height < 0 {
+			continue
+		}
+
+		block, err := u.rpc.GetBlockByHeight(height)
+		if err != nil {
+			return StatusLost, nil, 0, nil, fmt.Errorf("fetching block %v: %w", height, err)
+		}
+		if block == nil {
+			return StatusLost, nil, 0, nil, fmt.Errorf("node is missing block %v", height)
+		}
+		ancestorHashes[height] = block.Hash
+
+		if matchCandidate(block, candidate) {
+			return StatusMatured, block, height, ancestorHashes, nil
+		}
+
+		if !u.config.KeepUncles || !u.network.HasUncles() {
+			continue
+		}
+
+		for index, uncleHash := range block.Uncles {
+			uncle, err := u.rpc.GetUncleByBlockNumberAndIndex(height, index)
+			if err != nil {
+				return StatusLost, nil, 0, nil, fmt.Errorf("fetching uncle %v of block %v: %w", uncleHash, height, err)
+			}
+			if uncle != nil && matchCandidate(uncle, candidate) {
+				uncleHeight, err := parseHexInt64(uncle.Number)
+				if err != nil {
+					return StatusLost, nil, 0, nil, fmt.Errorf("parsing uncle height for uncle %v of block %v: %w", uncleHash, height, err)
+				}
+				candidate.Uncle = true
+				candidate.UncleHeight = uncleHeight
+				return StatusMatured, uncle, height, ancestorHashes, nil
+			}
+		}
+	}
+	return StatusOrphan, nil, 0, ancestorHashes, nil
+}
+
+// verifyCheckpoints finds the highest checkpoint at or below includerHeight
+// and confirms the chain still agrees with it, fetching the ancestor hash at
+// that height from the node when classify's scan window didn't happen to
+// cover it (the normal case, since checkpoints pin old, trusted heights well
+// behind scanDepth). eth_getBlockByNumber always reflects the chain's
+// current canonical view at a height, so a single fetch there is equivalent
+// to walking the ancestor chain down to it.
+func (u *BlockUnlocker) verifyCheckpoints(includerHeight int64, ancestorHashes map[int64]string) error {
+	checkpoints := u.network.CheckpointList()
+	var highest *Checkpoint
+	for i, cp := range checkpoints {
+		if cp.Height > includerHeight {
+			continue
+		}
+		if highest == nil || cp.Height > highest.Height {
+			highest = &checkpoints[i]
+		}
+	}
+	if highest == nil {
+		return nil
+	}
+
+	hash, ok := ancestorHashes[highest.Height]
+	if !ok {
+		block, err := u.rpc.GetBlockByHeight(highest.Height)
+		if err != nil {
+			return fmt.Errorf("fetching checkpoint ancestor block %v: %w", highest.Height, err)
+		}
+		if block == nil {
+			return fmt.Errorf("node is missing checkpoint ancestor block %v", highest.Height)
+		}
+		hash = block.Hash
+	}
+
+	return VerifyCheckpoints(u.network, includerHeight, map[int64]string{highest.Height: hash})
+}
+
+func matchCandidate(block *rpc.GetBlockReply, candidate *storage.BlockData) bool {
+	if len(candidate.Hash) > 0 && strings.EqualFold(candidate.Hash, block.Hash) {
+		return true
+	}
+	if len(block.Nonce) > 0 {
+		return strings.EqualFold(block.Nonce, candidate.Nonce)
+	}
+	// Parity's EIP: https://github.com/ethereum/EIPs/issues/95
+	if len(block.SealFields) == 2 {
+		return strings.EqualFold(candidate.Nonce, block.SealFields[1])
+	}
+	return false
+}
+
+// applyReward fills in candidate's final Reward: the canonical block reward
+// (or this uncle's share of one), plus nephew credit for any uncles the
+// canonical block included, plus transaction fees. includerHeight is the
+// canonical chain height classify matched against — for an uncle, that's the
+// nephew block that included it, not the uncle's own (lower) mined height.
+func (u *BlockUnlocker) applyReward(candidate *storage.BlockData, block *rpc.GetBlockReply, includerHeight int64) error {
+	var reward *big.Rat
+	if candidate.Uncle {
+		reward = u.network.UncleReward(includerHeight, candidate.UncleHeight)
+	} else {
+		reward = new(big.Rat).Set(u.network.RewardAt(includerHeight))
+		if u.config.KeepUncles && u.network.HasUncles() {
+			for range block.Uncles {
+				reward.Add(reward, NephewReward(u.network, includerHeight))
+			}
+		}
+	}
+
+	txFees, err := u.txFeeReward(block)
+	if err != nil {
+		return fmt.Errorf("fetching tx receipts for block %v: %w", includerHeight, err)
+	}
+	reward.Add(reward, txFees)
+
+	candidate.Height = includerHeight
+	candidate.Hash = block.Hash
+	candidate.Orphan = false
+	candidate.Reward = ratToWei(reward)
+	return nil
+}
+
+// ratToWei converts a wei *big.Rat (as produced by Network.RewardAt and the
+// uncle/nephew/tx-fee math) into the *big.Int storage.BlockData.Reward is
+// stored as, truncating any fractional wei left by the uncle and nephew
+// divisions.
+func ratToWei(r *big.Rat) *big.Int {
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
+func (u *BlockUnlocker) txFeeReward(block *rpc.GetBlockReply) (*big.Rat, error) {
+	total := new(big.Int)
+	for _, tx := range block.Transactions {
+		receipt, err := u.rpc.GetTxReceipt(tx.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if receipt == nil {
+			continue
+		}
+		gasUsed, ok := new(big.Int).SetString(strings.TrimPrefix(receipt.GasUsed, "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("unparseable gasUsed %q in receipt for %v", receipt.GasUsed, tx.Hash)
+		}
+		gasPrice, ok := new(big.Int).SetString(strings.TrimPrefix(tx.GasPrice, "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("unparseable gasPrice %q for tx %v", tx.GasPrice, tx.Hash)
+		}
+		total.Add(total, new(big.Int).Mul(gasUsed, gasPrice))
+	}
+	return new(big.Rat).SetInt(total), nil
+}
+
+// creditRound computes each miner's share of block's reward from the round's
+// recorded shares and persists it via write (WriteImmatureBlock or
+// WriteMaturedBlock, depending on which phase called us).
+func (u *BlockUnlocker) creditRound(block *storage.BlockData, write func(*storage.BlockData, map[string]int64) error) error {
+	shares, err := u.backend.GetRoundShares(block.RoundHeight, block.Nonce)
+	if err != nil {
+		return err
+	}
+
+	reward := new(big.Rat).SetInt(block.Reward)
+	roundRewards := distributeReward(reward, shares)
+	return write(block, roundRewards)
+}
+
+func distributeReward(reward *big.Rat, shares map[string]int64) map[string]int64 {
+	var total int64
+	for _, n := range shares {
+		total += n
+	}
+
+	rewards := make(map[string]int64, len(shares))
+	if total == 0 {
+		return rewards
+	}
+	for login, n := range shares {
+		percent := big.NewRat(n, total)
+		rewards[login] += weiToShannon(new(big.Rat).Mul(reward, percent))
+	}
+	return rewards
+}
+
+func weiToShannon(wei *big.Rat) int64 {
+	inShannon := new(big.Rat).Quo(wei, new(big.Rat).SetInt(shannon))
+	value, _ := strconv.ParseInt(inShannon.FloatString(0), 10, 64)
+	return value
+}
 
-	// Test testnet reward schedule
-	fmt.Println("---- Zether Testnet ----")
-	SimulateUnlock(ZetherTestnet, 500)    // Check reward before adjustment
-	SimulateUnlock(ZetherTestnet, 1500)   // Check reward after first adjustment
+func parseHexInt64(hex string) (int64, error) {
+	return strconv.ParseInt(strings.TrimPrefix(hex, "0x"), 16, 64)
 }
@@ -0,0 +1,343 @@
+package payouts
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/yuriy0803/open-etc-pool-friends/rpc"
+	"github.com/yuriy0803/open-etc-pool-friends/storage"
+)
+
+// fakeRPC is a fake RPC transport driving the unlocker without a live node.
+type fakeRPC struct {
+	pending  *rpc.GetBlockReplyPart
+	blocks   map[int64]*rpc.GetBlockReply
+	uncles   map[string]*rpc.GetBlockReply // key: fmt.Sprintf("%d:%d", height, index)
+	receipts map[string]*rpc.TxReceipt     // key: tx hash
+}
+
+func newFakeRPC() *fakeRPC {
+	return &fakeRPC{
+		blocks:   make(map[int64]*rpc.GetBlockReply),
+		uncles:   make(map[string]*rpc.GetBlockReply),
+		receipts: make(map[string]*rpc.TxReceipt),
+	}
+}
+
+func (f *fakeRPC) GetPendingBlock() (*rpc.GetBlockReplyPart, error) { return f.pending, nil }
+
+func (f *fakeRPC) GetBlockByHeight(height int64) (*rpc.GetBlockReply, error) {
+	if block, ok := f.blocks[height]; ok {
+		return block, nil
+	}
+	return &rpc.GetBlockReply{Number: fmt.Sprintf("0x%x", height)}, nil
+}
+
+func (f *fakeRPC) GetUncleByBlockNumberAndIndex(height int64, index int) (*rpc.GetBlockReply, error) {
+	return f.uncles[fmt.Sprintf("%d:%d", height, index)], nil
+}
+
+func (f *fakeRPC) GetTxReceipt(hash string) (*rpc.TxReceipt, error) {
+	return f.receipts[hash], nil
+}
+
+// fakeBackend is an in-memory unlockerBackend.
+type fakeBackend struct {
+	candidates []*storage.BlockData
+	immature   []*storage.BlockData
+	shares     map[string]map[string]int64 // key: fmt.Sprintf("%d:%s", height, nonce)
+
+	writtenImmature []*storage.BlockData
+	writtenMatured  []*storage.BlockData
+	writtenOrphans  []*storage.BlockData
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{shares: make(map[string]map[string]int64)}
+}
+
+func (f *fakeBackend) GetCandidates(maxHeight int64) ([]*storage.BlockData, error) {
+	var out []*storage.BlockData
+	for _, c := range f.candidates {
+		if c.Height <= maxHeight {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) GetImmatureBlocks(maxHeight int64) ([]*storage.BlockData, error) {
+	var out []*storage.BlockData
+	for _, c := range f.immature {
+		if c.Height <= maxHeight {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) GetRoundShares(height int64, nonce string) (map[string]int64, error) {
+	return f.shares[fmt.Sprintf("%d:%s", height, nonce)], nil
+}
+
+func (f *fakeBackend) WritePendingOrphans(blocks []*storage.BlockData) error {
+	f.writtenOrphans = append(f.writtenOrphans, blocks...)
+	return nil
+}
+
+func (f *fakeBackend) WriteOrphan(block *storage.BlockData) error {
+	f.writtenOrphans = append(f.writtenOrphans, block)
+	return nil
+}
+
+func (f *fakeBackend) WriteImmatureBlock(block *storage.BlockData, roundRewards map[string]int64) error {
+	f.writtenImmature = append(f.writtenImmature, block)
+	return nil
+}
+
+func (f *fakeBackend) WriteMaturedBlock(block *storage.BlockData, roundRewards map[string]int64) error {
+	f.writtenMatured = append(f.writtenMatured, block)
+	return nil
+}
+
+func testNetwork() Network {
+	return newEpochNetwork("test", []RewardEpoch{{Height: 0, Reward: weiRat("5000000000000000000")}}, 1000, false, nil, 5)
+}
+
+func testUncleNetwork() Network {
+	return newEpochNetwork("test", []RewardEpoch{{Height: 0, Reward: weiRat("5000000000000000000")}}, 1000, true, nil, 5)
+}
+
+func newTestUnlocker(network Network, rpcClient nodeRPC, backend unlockerBackend) *BlockUnlocker {
+	return &BlockUnlocker{
+		config:  &PayoutsConfig{MatureDepth: 10, UnlockBatchSize: 10, KeepUncles: true},
+		network: network,
+		backend: backend,
+		rpc:     rpcClient,
+	}
+}
+
+func TestResolveCandidatesMatured(t *testing.T) {
+	rpcClient := newFakeRPC()
+	rpcClient.blocks[100] = &rpc.GetBlockReply{Number: "0x64", Hash: "0xblock100", Nonce: "0xabc"}
+
+	candidate := &storage.BlockData{Height: 100, Nonce: "0xabc", RoundHeight: 100}
+	u := newTestUnlocker(testNetwork(), rpcClient, newFakeBackend())
+
+	matured, orphaned, err := u.resolveCandidates([]*storage.BlockData{candidate})
+	if err != nil {
+		t.Fatalf("resolveCandidates returned error: %v", err)
+	}
+	if len(orphaned) != 0 {
+		t.Fatalf("expected no orphans, got %d", len(orphaned))
+	}
+	if len(matured) != 1 {
+		t.Fatalf("expected 1 matured block, got %d", len(matured))
+	}
+	want := weiRat("5000000000000000000")
+	if got := new(big.Rat).SetInt(matured[0].Reward); got.Cmp(want) != 0 {
+		t.Errorf("Reward = %s, want %s", got, want)
+	}
+}
+
+func TestResolveCandidatesOrphan(t *testing.T) {
+	rpcClient := newFakeRPC() // no block anywhere matches this nonce
+	candidate := &storage.BlockData{Height: 100, Nonce: "0xdoesnotexist", RoundHeight: 100}
+	u := newTestUnlocker(testNetwork(), rpcClient, newFakeBackend())
+
+	matured, orphaned, err := u.resolveCandidates([]*storage.BlockData{candidate})
+	if err != nil {
+		t.Fatalf("resolveCandidates returned error: %v", err)
+	}
+	if len(matured) != 0 {
+		t.Fatalf("expected no matured blocks, got %d", len(matured))
+	}
+	if len(orphaned) != 1 || !orphaned[0].Orphan {
+		t.Fatalf("expected candidate to be marked orphaned, got %+v", orphaned)
+	}
+}
+
+func TestResolveCandidatesHaltsOnCheckpointMismatch(t *testing.T) {
+	network := newEpochNetwork("test", []RewardEpoch{{Height: 0, Reward: weiRat("1000000000000000000")}}, 1000,
+		false, []Checkpoint{{Height: 100, Hash: "0xexpected"}}, 5)
+
+	rpcClient := newFakeRPC()
+	rpcClient.blocks[100] = &rpc.GetBlockReply{Number: "0x64", Hash: "0xunexpected", Nonce: "0xabc"}
+	candidate := &storage.BlockData{Height: 100, Nonce: "0xabc", RoundHeight: 100}
+	u := newTestUnlocker(network, rpcClient, newFakeBackend())
+
+	_, _, err := u.resolveCandidates([]*storage.BlockData{candidate})
+	if err == nil {
+		t.Fatal("expected a checkpoint mismatch to refuse unlocking")
+	}
+	var mismatch *CheckpointMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected the error to wrap *CheckpointMismatch, got %v", err)
+	}
+}
+
+func TestResolveCandidatesUncleMatch(t *testing.T) {
+	rpcClient := newFakeRPC()
+	rpcClient.blocks[105] = &rpc.GetBlockReply{Number: "0x69", Hash: "0xblock105", Nonce: "0xcanonical", Uncles: []string{"0xunclehash"}}
+	rpcClient.uncles["105:0"] = &rpc.GetBlockReply{Number: "0x68", Hash: "0xunclehash", Nonce: "0xuncle"}
+
+	candidate := &storage.BlockData{Height: 104, Nonce: "0xuncle", RoundHeight: 104}
+	u := newTestUnlocker(testUncleNetwork(), rpcClient, newFakeBackend())
+
+	matured, orphaned, err := u.resolveCandidates([]*storage.BlockData{candidate})
+	if err != nil {
+		t.Fatalf("resolveCandidates returned error: %v", err)
+	}
+	if len(orphaned) != 0 {
+		t.Fatalf("expected no orphans, got %d", len(orphaned))
+	}
+	if len(matured) != 1 {
+		t.Fatalf("expected 1 matured block, got %d", len(matured))
+	}
+
+	got := matured[0]
+	if !got.Uncle {
+		t.Fatal("expected candidate to be marked as an uncle")
+	}
+	if got.UncleHeight != 104 {
+		t.Errorf("UncleHeight = %v, want 104", got.UncleHeight)
+	}
+	if got.Height != 105 {
+		t.Errorf("Height = %v, want the includer height 105", got.Height)
+	}
+
+	// depth 1: ((104 + 8 - 105) * 5e18) / 8 = 4.375e18, not the full base reward.
+	want := ratToWei(new(big.Rat).Quo(new(big.Rat).Mul(weiRat("5000000000000000000"), big.NewRat(7, 1)), big.NewRat(8, 1)))
+	if got.Reward.Cmp(want) != 0 {
+		t.Errorf("Reward = %s, want %s", got.Reward, want)
+	}
+}
+
+func TestResolveCandidatesIgnoresUnclesWhenKeepUnclesDisabled(t *testing.T) {
+	rpcClient := newFakeRPC()
+	rpcClient.blocks[105] = &rpc.GetBlockReply{Number: "0x69", Hash: "0xblock105", Nonce: "0xcanonical", Uncles: []string{"0xunclehash"}}
+	rpcClient.uncles["105:0"] = &rpc.GetBlockReply{Number: "0x68", Hash: "0xunclehash", Nonce: "0xuncle"}
+
+	candidate := &storage.BlockData{Height: 104, Nonce: "0xuncle", RoundHeight: 104}
+	u := newTestUnlocker(testUncleNetwork(), rpcClient, newFakeBackend())
+	u.config.KeepUncles = false
+
+	matured, orphaned, err := u.resolveCandidates([]*storage.BlockData{candidate})
+	if err != nil {
+		t.Fatalf("resolveCandidates returned error: %v", err)
+	}
+	if len(matured) != 0 {
+		t.Fatalf("expected uncle match to be ignored, got %d matured", len(matured))
+	}
+	if len(orphaned) != 1 || !orphaned[0].Orphan {
+		t.Fatalf("expected the candidate to age into an orphan, got %+v", orphaned)
+	}
+}
+
+func TestResolveCandidatesVerifiesCheckpointOutsideScanWindow(t *testing.T) {
+	network := newEpochNetwork("test", []RewardEpoch{{Height: 0, Reward: weiRat("5000000000000000000")}}, 1000,
+		false, []Checkpoint{{Height: 0, Hash: "0xgenesis"}}, 5)
+
+	rpcClient := newFakeRPC()
+	rpcClient.blocks[0] = &rpc.GetBlockReply{Number: "0x0", Hash: "0xgenesis"}
+	rpcClient.blocks[1000] = &rpc.GetBlockReply{Number: "0x3e8", Hash: "0xblock1000", Nonce: "0xabc"}
+	candidate := &storage.BlockData{Height: 1000, Nonce: "0xabc", RoundHeight: 1000}
+	u := newTestUnlocker(network, rpcClient, newFakeBackend())
+
+	matured, _, err := u.resolveCandidates([]*storage.BlockData{candidate})
+	if err != nil {
+		t.Fatalf("resolveCandidates returned error: %v", err)
+	}
+	if len(matured) != 1 {
+		t.Fatalf("expected 1 matured block, got %d", len(matured))
+	}
+}
+
+func TestResolveCandidatesHaltsOnCheckpointMismatchOutsideScanWindow(t *testing.T) {
+	network := newEpochNetwork("test", []RewardEpoch{{Height: 0, Reward: weiRat("5000000000000000000")}}, 1000,
+		false, []Checkpoint{{Height: 0, Hash: "0xgenesis"}}, 5)
+
+	rpcClient := newFakeRPC()
+	rpcClient.blocks[0] = &rpc.GetBlockReply{Number: "0x0", Hash: "0xreorged"}
+	rpcClient.blocks[1000] = &rpc.GetBlockReply{Number: "0x3e8", Hash: "0xblock1000", Nonce: "0xabc"}
+	candidate := &storage.BlockData{Height: 1000, Nonce: "0xabc", RoundHeight: 1000}
+	u := newTestUnlocker(network, rpcClient, newFakeBackend())
+
+	_, _, err := u.resolveCandidates([]*storage.BlockData{candidate})
+	if err == nil {
+		t.Fatal("expected a checkpoint well outside scanDepth to still gate unlocking")
+	}
+	var mismatch *CheckpointMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected the error to wrap *CheckpointMismatch, got %v", err)
+	}
+}
+
+func TestApplyRewardIncludesTxFees(t *testing.T) {
+	rpcClient := newFakeRPC()
+	block := &rpc.GetBlockReply{
+		Number: "0x64",
+		Hash:   "0xblock100",
+		Nonce:  "0xabc",
+		Transactions: []rpc.Tx{
+			{Hash: "0xtx1", GasPrice: "0x3b9aca00"}, // 1 Gwei
+		},
+	}
+	rpcClient.blocks[100] = block
+	rpcClient.receipts["0xtx1"] = &rpc.TxReceipt{GasUsed: "0x5208"} // 21000 gas
+
+	candidate := &storage.BlockData{Height: 100, Nonce: "0xabc", RoundHeight: 100}
+	u := newTestUnlocker(testNetwork(), rpcClient, newFakeBackend())
+
+	if err := u.applyReward(candidate, block, 100); err != nil {
+		t.Fatalf("applyReward returned error: %v", err)
+	}
+
+	fee := new(big.Int).Mul(big.NewInt(21000), big.NewInt(1000000000))
+	want := new(big.Int).Add(ratToWei(weiRat("5000000000000000000")), fee)
+	if candidate.Reward.Cmp(want) != 0 {
+		t.Errorf("Reward = %s, want %s", candidate.Reward, want)
+	}
+}
+
+func TestDistributeRewardProportional(t *testing.T) {
+	reward := weiRat("5000000000000000000")
+	shares := map[string]int64{"0xa": 3, "0xb": 1}
+
+	rewards := distributeReward(reward, shares)
+	if rewards["0xa"] <= rewards["0xb"] {
+		t.Errorf("expected 0xa's larger share to earn more: %v vs %v", rewards["0xa"], rewards["0xb"])
+	}
+	if rewards["0xa"]+rewards["0xb"] == 0 {
+		t.Error("expected a non-zero total distributed reward")
+	}
+}
+
+func TestDistributeRewardNoShares(t *testing.T) {
+	rewards := distributeReward(weiRat("5000000000000000000"), map[string]int64{})
+	if len(rewards) != 0 {
+		t.Errorf("expected no rewards with no shares, got %v", rewards)
+	}
+}
+
+func TestUnlockPendingBlocksCreditsImmature(t *testing.T) {
+	rpcClient := newFakeRPC()
+	rpcClient.pending = &rpc.GetBlockReplyPart{Number: "0x70"} // tip = 112
+	rpcClient.blocks[100] = &rpc.GetBlockReply{Number: "0x64", Hash: "0xblock100", Nonce: "0xabc"}
+
+	backend := newFakeBackend()
+	backend.candidates = []*storage.BlockData{{Height: 100, Nonce: "0xabc", RoundHeight: 100}}
+	backend.shares["100:0xabc"] = map[string]int64{"0xminer": 1}
+
+	u := newTestUnlocker(testNetwork(), rpcClient, backend)
+	u.unlockPendingBlocks()
+
+	if u.halt {
+		t.Fatalf("unlocker halted unexpectedly: %v", u.lastFail)
+	}
+	if len(backend.writtenImmature) != 1 {
+		t.Fatalf("expected 1 immature block written, got %d", len(backend.writtenImmature))
+	}
+}
@@ -0,0 +1,217 @@
+// File: rpc.go
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RPCClient is a minimal JSON-RPC client for an Ethereum-family node,
+// tracking a sick/alive state so callers can back off a flapping node.
+type RPCClient struct {
+	sync.RWMutex
+	Url         string
+	Name        string
+	sick        bool
+	sickRate    int
+	successRate int
+	client      *http.Client
+}
+
+// NewRPCClient dials the node at url, giving up on a request after timeout
+// (a duration string like "10s").
+func NewRPCClient(name, url, timeout string) *RPCClient {
+	timeoutIntv, err := time.ParseDuration(timeout)
+	if err != nil {
+		timeoutIntv = 10 * time.Second
+	}
+	return &RPCClient{
+		Name: name,
+		Url:  url,
+		client: &http.Client{
+			Timeout: timeoutIntv,
+		},
+	}
+}
+
+// GetBlockReply is the result of eth_getBlockByNumber/eth_getBlockByHash/
+// eth_getUncleByBlockNumberAndIndex.
+type GetBlockReply struct {
+	Number       string   `json:"number"`
+	Hash         string   `json:"hash"`
+	Nonce        string   `json:"nonce"`
+	Difficulty   string   `json:"difficulty"`
+	GasLimit     string   `json:"gasLimit"`
+	GasUsed      string   `json:"gasUsed"`
+	Timestamp    string   `json:"timestamp"`
+	Transactions []Tx     `json:"transactions"`
+	Uncles       []string `json:"uncles"`
+	// https://github.com/ethereum/EIPs/issues/95
+	SealFields []string `json:"sealFields"`
+}
+
+// GetBlockReplyPart is the trimmed reply used for the pending block, which
+// only needs to expose the chain's current height.
+type GetBlockReplyPart struct {
+	Number string `json:"number"`
+}
+
+// Tx is a single transaction as embedded in a GetBlockReply.
+type Tx struct {
+	Hash     string `json:"hash"`
+	GasPrice string `json:"gasPrice"`
+}
+
+const receiptStatusSuccessful = "0x1"
+
+// TxReceipt is the result of eth_getTransactionReceipt.
+type TxReceipt struct {
+	TxHash    string `json:"transactionHash"`
+	GasUsed   string `json:"gasUsed"`
+	BlockHash string `json:"blockHash"`
+	Status    string `json:"status"`
+}
+
+// Confirmed reports whether the transaction has been mined into a block.
+func (r *TxReceipt) Confirmed() bool {
+	return len(r.BlockHash) > 0
+}
+
+// Successful reports whether the transaction didn't revert. Use alongside
+// Confirmed, since pre-Byzantium receipts don't carry a status at all.
+func (r *TxReceipt) Successful() bool {
+	if len(r.Status) > 0 {
+		return r.Status == receiptStatusSuccessful
+	}
+	return true
+}
+
+type jsonRPCResp struct {
+	Id     *json.RawMessage       `json:"id"`
+	Result *json.RawMessage       `json:"result"`
+	Error  map[string]interface{} `json:"error"`
+}
+
+// GetPendingBlock returns the chain's pending block, used to read the
+// current tip height.
+func (r *RPCClient) GetPendingBlock() (*GetBlockReplyPart, error) {
+	resp, err := r.doPost(r.Url, "eth_getBlockByNumber", []interface{}{"pending", false})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Result == nil {
+		return nil, nil
+	}
+	var reply *GetBlockReplyPart
+	err = json.Unmarshal(*resp.Result, &reply)
+	return reply, err
+}
+
+// GetBlockByHeight returns the canonical block at height.
+func (r *RPCClient) GetBlockByHeight(height int64) (*GetBlockReply, error) {
+	params := []interface{}{fmt.Sprintf("0x%x", height), true}
+	return r.getBlockBy("eth_getBlockByNumber", params)
+}
+
+// GetUncleByBlockNumberAndIndex returns the uncle at index within the
+// canonical block at height, or nil if there is none.
+func (r *RPCClient) GetUncleByBlockNumberAndIndex(height int64, index int) (*GetBlockReply, error) {
+	params := []interface{}{fmt.Sprintf("0x%x", height), fmt.Sprintf("0x%x", index)}
+	return r.getBlockBy("eth_getUncleByBlockNumberAndIndex", params)
+}
+
+func (r *RPCClient) getBlockBy(method string, params []interface{}) (*GetBlockReply, error) {
+	resp, err := r.doPost(r.Url, method, params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Result == nil {
+		return nil, nil
+	}
+	var reply *GetBlockReply
+	err = json.Unmarshal(*resp.Result, &reply)
+	return reply, err
+}
+
+// GetTxReceipt returns the receipt for the transaction identified by hash, or
+// nil if it hasn't been mined yet.
+func (r *RPCClient) GetTxReceipt(hash string) (*TxReceipt, error) {
+	resp, err := r.doPost(r.Url, "eth_getTransactionReceipt", []string{hash})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Result == nil {
+		return nil, nil
+	}
+	var reply *TxReceipt
+	err = json.Unmarshal(*resp.Result, &reply)
+	return reply, err
+}
+
+func (r *RPCClient) doPost(url, method string, params interface{}) (*jsonRPCResp, error) {
+	jsonReq := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params, "id": 0}
+	data, err := json.Marshal(jsonReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		r.markSick()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var rpcResp *jsonRPCResp
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		r.markSick()
+		return nil, err
+	}
+	if rpcResp.Error != nil {
+		r.markSick()
+		return nil, errors.New(rpcResp.Error["message"].(string))
+	}
+	r.markAlive()
+	return rpcResp, nil
+}
+
+// Sick reports whether the node has been failing requests recently.
+func (r *RPCClient) Sick() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.sick
+}
+
+func (r *RPCClient) markSick() {
+	r.Lock()
+	r.sickRate++
+	r.successRate = 0
+	if r.sickRate >= 5 {
+		r.sick = true
+	}
+	r.Unlock()
+}
+
+func (r *RPCClient) markAlive() {
+	r.Lock()
+	r.successRate++
+	if r.successRate >= 5 {
+		r.sick = false
+		r.sickRate = 0
+		r.successRate = 0
+	}
+	r.Unlock()
+}
@@ -0,0 +1,312 @@
+// File: redis.go
+
+package storage
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"gopkg.in/redis.v3"
+)
+
+// Config configures the Redis instance backing a RedisClient.
+type Config struct {
+	Endpoint string `json:"endpoint"`
+	Password string `json:"password"`
+	Database int64  `json:"database"`
+	PoolSize int    `json:"poolSize"`
+}
+
+// RedisClient is the pool's view of Redis: round candidates, immature and
+// matured blocks, and the per-round share ledgers the unlocker credits from.
+type RedisClient struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisClient connects to the Redis instance described by cfg, namespacing
+// every key it touches under prefix.
+func NewRedisClient(cfg *Config, prefix string) *RedisClient {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Endpoint,
+		Password: cfg.Password,
+		DB:       cfg.Database,
+		PoolSize: cfg.PoolSize,
+	})
+	return &RedisClient{client: client, prefix: prefix}
+}
+
+// Client returns the underlying redis.Client for callers that need lower
+// level access (health checks, admin commands).
+func (r *RedisClient) Client() *redis.Client {
+	return r.client
+}
+
+// BlockData is a round candidate as it moves from candidate -> immature ->
+// matured (or orphan).
+type BlockData struct {
+	Height       int64    `json:"height"`
+	RoundHeight  int64    `json:"-"`
+	Timestamp    int64    `json:"timestamp"`
+	Difficulty   int64    `json:"difficulty"`
+	TotalShares  int64    `json:"shares"`
+	Uncle        bool     `json:"uncle"`
+	UncleHeight  int64    `json:"uncleHeight"`
+	Orphan       bool     `json:"orphan"`
+	Hash         string   `json:"hash"`
+	Finder       string   `json:"finder"`
+	Nonce        string   `json:"-"`
+	Reward       *big.Int `json:"-"`
+	RewardString string   `json:"reward"`
+
+	candidateKey string
+	immatureKey  string
+}
+
+// RoundKey identifies the round a block belongs to by its round height and
+// block hash. The share ledger itself is keyed by height and nonce (see
+// formatRound), not by this key.
+func (b *BlockData) RoundKey() string {
+	return join(b.RoundHeight, b.Hash)
+}
+
+func (b *BlockData) serializeHash() string {
+	if len(b.Hash) > 0 {
+		return b.Hash
+	}
+	return "0x0"
+}
+
+// key encodes block as a sorted-set member for the "blocks:immature" and
+// "blocks:matured" Z-sets; the block's height is carried separately as the
+// member's score.
+func (b *BlockData) key() string {
+	return join(b.UncleHeight, b.Orphan, b.Nonce, b.serializeHash(), b.Timestamp, b.Difficulty, b.TotalShares, b.Finder, b.RewardString)
+}
+
+func (r *RedisClient) formatKey(args ...interface{}) string {
+	return join(r.prefix, join(args...))
+}
+
+func (r *RedisClient) formatRound(height int64, nonce string) string {
+	return r.formatKey("shares", "round"+strconv.FormatInt(height, 10), nonce)
+}
+
+func join(args ...interface{}) string {
+	s := make([]string, len(args))
+	for i, v := range args {
+		switch x := v.(type) {
+		case string:
+			s[i] = x
+		case int64:
+			s[i] = strconv.FormatInt(x, 10)
+		case bool:
+			if x {
+				s[i] = "1"
+			} else {
+				s[i] = "0"
+			}
+		default:
+			panic("storage: invalid type for key join")
+		}
+	}
+	return strings.Join(s, ":")
+}
+
+// GetCandidates returns round candidates at or below maxHeight, i.e. ones the
+// unlocker can already search the chain for.
+func (r *RedisClient) GetCandidates(maxHeight int64) ([]*BlockData, error) {
+	option := redis.ZRangeByScore{Min: "0", Max: strconv.FormatInt(maxHeight, 10)}
+	cmd := r.client.ZRangeByScoreWithScores(r.formatKey("blocks", "candidates"), option)
+	if cmd.Err() != nil {
+		return nil, cmd.Err()
+	}
+	return convertCandidateResults(cmd), nil
+}
+
+// GetImmatureBlocks returns immature blocks at or below maxHeight, i.e. ones
+// old enough to finalize as matured or orphaned.
+func (r *RedisClient) GetImmatureBlocks(maxHeight int64) ([]*BlockData, error) {
+	option := redis.ZRangeByScore{Min: "0", Max: strconv.FormatInt(maxHeight, 10)}
+	cmd := r.client.ZRangeByScoreWithScores(r.formatKey("blocks", "immature"), option)
+	if cmd.Err() != nil {
+		return nil, cmd.Err()
+	}
+	return convertBlockResults(cmd), nil
+}
+
+func convertCandidateResults(raw *redis.ZSliceCmd) []*BlockData {
+	var result []*BlockData
+	for _, v := range raw.Val() {
+		// "nonce:finder"
+		block := &BlockData{}
+		block.Height = int64(v.Score)
+		block.RoundHeight = block.Height
+		fields := strings.Split(v.Member.(string), ":")
+		block.Nonce = fields[0]
+		if len(fields) > 1 {
+			block.Finder = fields[1]
+		}
+		block.candidateKey = v.Member.(string)
+		result = append(result, block)
+	}
+	return result
+}
+
+func convertBlockResults(raw *redis.ZSliceCmd) []*BlockData {
+	var result []*BlockData
+	for _, v := range raw.Val() {
+		// "uncleHeight:orphan:nonce:hash:timestamp:difficulty:totalShares:finder:reward"
+		block := &BlockData{}
+		block.Height = int64(v.Score)
+		block.RoundHeight = block.Height
+		fields := strings.Split(v.Member.(string), ":")
+		block.UncleHeight, _ = strconv.ParseInt(fields[0], 10, 64)
+		block.Uncle = block.UncleHeight > 0
+		block.Orphan, _ = strconv.ParseBool(fields[1])
+		block.Nonce = fields[2]
+		block.Hash = fields[3]
+		block.Timestamp, _ = strconv.ParseInt(fields[4], 10, 64)
+		block.Difficulty, _ = strconv.ParseInt(fields[5], 10, 64)
+		block.TotalShares, _ = strconv.ParseInt(fields[6], 10, 64)
+		block.Finder = fields[7]
+		block.RewardString = fields[8]
+		block.immatureKey = v.Member.(string)
+		result = append(result, block)
+	}
+	return result
+}
+
+// GetRoundShares returns each miner's recorded share count for the round
+// identified by height and nonce.
+func (r *RedisClient) GetRoundShares(height int64, nonce string) (map[string]int64, error) {
+	result := make(map[string]int64)
+	cmd := r.client.HGetAllMap(r.formatRound(height, nonce))
+	if cmd.Err() != nil {
+		return nil, cmd.Err()
+	}
+	sharesMap, _ := cmd.Result()
+	for login, v := range sharesMap {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		result[login] = n
+	}
+	return result, nil
+}
+
+// WritePendingOrphans moves a batch of stale round candidates directly into
+// the immature pool so unlockAndCreditMiners can finish resolving them later,
+// mirroring the single-block path below but without crediting any shares.
+func (r *RedisClient) WritePendingOrphans(blocks []*BlockData) error {
+	tx := r.client.Multi()
+	defer tx.Close()
+
+	_, err := tx.Exec(func() error {
+		for _, block := range blocks {
+			r.writeImmatureBlock(tx, block)
+		}
+		return nil
+	})
+	return err
+}
+
+// WriteImmatureBlock moves a matured-but-not-yet-final candidate into the
+// immature pool and credits roundRewards provisionally.
+func (r *RedisClient) WriteImmatureBlock(block *BlockData, roundRewards map[string]int64) error {
+	tx := r.client.Multi()
+	defer tx.Close()
+
+	_, err := tx.Exec(func() error {
+		r.writeImmatureBlock(tx, block)
+
+		total := int64(0)
+		for login, amount := range roundRewards {
+			total += amount
+			tx.HIncrBy(r.formatKey("miners", login), "immature", amount)
+			tx.HSetNX(r.formatKey("credits", "immature", block.Height, block.Hash), login, strconv.FormatInt(amount, 10))
+		}
+		tx.HIncrBy(r.formatKey("finances"), "immature", total)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisClient) writeImmatureBlock(tx *redis.Multi, block *BlockData) {
+	if block.Height != block.RoundHeight {
+		tx.Rename(r.formatRound(block.RoundHeight, block.Nonce), r.formatRound(block.Height, block.Nonce))
+	}
+	tx.ZRem(r.formatKey("blocks", "candidates"), block.candidateKey)
+	tx.ZAdd(r.formatKey("blocks", "immature"), redis.Z{Score: float64(block.Height), Member: block.key()})
+}
+
+// WriteMaturedBlock finalizes an immature block as matured, converting each
+// miner's provisional immature credit into a real balance credit.
+func (r *RedisClient) WriteMaturedBlock(block *BlockData, roundRewards map[string]int64) error {
+	creditKey := r.formatKey("credits", "immature", block.RoundHeight, block.Hash)
+	tx, err := r.client.Watch(creditKey)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	immatureCredits := tx.HGetAllMap(creditKey)
+
+	_, err = tx.Exec(func() error {
+		r.writeMaturedBlock(tx, block)
+
+		totalImmature := int64(0)
+		for login, amountString := range immatureCredits.Val() {
+			amount, _ := strconv.ParseInt(amountString, 10, 64)
+			totalImmature += amount
+			tx.HIncrBy(r.formatKey("miners", login), "immature", amount*-1)
+		}
+
+		total := int64(0)
+		for login, amount := range roundRewards {
+			total += amount
+			tx.HIncrBy(r.formatKey("miners", login), "balance", amount)
+			if amount > 0 {
+				tx.HSetNX(r.formatKey("credits", block.Height, block.Hash), login, strconv.FormatInt(amount, 10))
+			}
+		}
+		tx.Del(creditKey)
+		tx.HIncrBy(r.formatKey("finances"), "balance", total)
+		tx.HIncrBy(r.formatKey("finances"), "immature", totalImmature*-1)
+		tx.HSet(r.formatKey("finances"), "lastCreditHeight", strconv.FormatInt(block.Height, 10))
+		tx.HSet(r.formatKey("finances"), "lastCreditHash", block.Hash)
+		return nil
+	})
+	return err
+}
+
+// WriteOrphan finalizes an immature block as orphaned, unwinding the
+// provisional immature credit without ever crediting a balance.
+func (r *RedisClient) WriteOrphan(block *BlockData) error {
+	creditKey := r.formatKey("credits", "immature", block.RoundHeight, block.Hash)
+	tx, err := r.client.Watch(creditKey)
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	immatureCredits := tx.HGetAllMap(creditKey)
+
+	_, err = tx.Exec(func() error {
+		r.writeMaturedBlock(tx, block)
+
+		totalImmature := int64(0)
+		for login, amountString := range immatureCredits.Val() {
+			amount, _ := strconv.ParseInt(amountString, 10, 64)
+			totalImmature += amount
+			tx.HIncrBy(r.formatKey("miners", login), "immature", amount*-1)
+		}
+		tx.Del(creditKey)
+		tx.HIncrBy(r.formatKey("finances"), "immature", totalImmature*-1)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisClient) writeMaturedBlock(tx *redis.Multi, block *BlockData) {
+	tx.Del(r.formatRound(block.RoundHeight, block.Nonce))
+	tx.ZRem(r.formatKey("blocks", "immature"), block.immatureKey)
+	tx.ZAdd(r.formatKey("blocks", "matured"), redis.Z{Score: float64(block.Height), Member: block.key()})
+}